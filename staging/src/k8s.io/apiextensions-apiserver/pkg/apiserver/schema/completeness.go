@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateStructuralCompleteness checks that every property and array
+// mentioned inside value validation (allOf, anyOf, oneOf, not, if, then,
+// else) is also
+// specified outside of it, i.e. via Properties/Items of s itself or of the
+// enclosing Structural the value validation hangs off of.
+func validateStructuralCompleteness(s *Structural, fldPath *field.Path) field.ErrorList {
+	if s == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateStructuralCompletenessWithGenericValidation(s, s.ValueValidation, fldPath)...)
+
+	if s.Items != nil {
+		allErrs = append(allErrs, validateStructuralCompleteness(s.Items, fldPath.Child("items"))...)
+	}
+	for k, v := range s.Properties {
+		allErrs = append(allErrs, validateStructuralCompleteness(&v, fldPath.Child("properties").Key(k))...)
+	}
+
+	return allErrs
+}
+
+// validateStructuralCompletenessWithGenericValidation checks the logic
+// junctors of a value validation against the structural schema sts that the
+// value validation is attached to.
+func validateStructuralCompletenessWithGenericValidation(sts *Structural, node *ValueValidation, fldPath *field.Path) field.ErrorList {
+	if node == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	for i := range node.AllOf {
+		allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, &node.AllOf[i], fldPath.Child("allOf").Index(i))...)
+	}
+	for i := range node.AnyOf {
+		allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, &node.AnyOf[i], fldPath.Child("anyOf").Index(i))...)
+	}
+	for i := range node.OneOf {
+		allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, &node.OneOf[i], fldPath.Child("oneOf").Index(i))...)
+	}
+	allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, node.Not, fldPath.Child("not"))...)
+
+	allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, node.If, fldPath.Child("if"))...)
+	allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, node.Then, fldPath.Child("then"))...)
+	allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts, node.Else, fldPath.Child("else"))...)
+
+	return allErrs
+}
+
+// validateStructuralCompletenessOfNested checks a single nested value
+// validation node against the structural schema sts it is attached to,
+// recursing into its own Items/Properties and further junctors.
+func validateStructuralCompletenessOfNested(sts *Structural, node *NestedValueValidation, fldPath *field.Path) field.ErrorList {
+	if node == nil || sts == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	if node.Items != nil {
+		if sts.Items == nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("items"), "must be specified in items outside value validation too"))
+		} else {
+			allErrs = append(allErrs, validateStructuralCompletenessOfNested(sts.Items, node.Items, fldPath.Child("items"))...)
+		}
+	}
+
+	for k, v := range node.Properties {
+		if prop, ok := sts.Properties[k]; ok {
+			allErrs = append(allErrs, validateStructuralCompletenessOfNested(&prop, &v, fldPath.Child("properties").Key(k))...)
+		} else {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("properties").Key(k), "must be specified in properties outside value validation too"))
+		}
+	}
+
+	allErrs = append(allErrs, validateStructuralCompletenessWithGenericValidation(sts, &node.ValueValidation, fldPath)...)
+
+	return allErrs
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// definitionsPrefix is the only ref shape this package resolves, matching
+// JSON Schema draft-07's replacement of `#/definitions/` with `#/$defs/`.
+const definitionsPrefix = "#/$defs/"
+
+// ResolveRefs walks s and returns a fully expanded copy with every $ref
+// inlined from s.Definitions. It rejects refs that do not point into
+// #/$defs/ and detects reference cycles (A -> B -> A).
+//
+// Every Structural reached through a $ref is copied before its own refs are
+// resolved, so expanding the same named definition at two call sites yields
+// separate Items, Properties and AdditionalProperties schemas. The copy is
+// shallow below that: slices and pointers inside ValueValidation (Enum,
+// Required, Maximum, ...), Default, and an AdditionalProperties that holds
+// only a bool are still shared with s.
+func ResolveRefs(s *Structural) (*Structural, field.ErrorList) {
+	if s == nil {
+		return nil, nil
+	}
+
+	r := &refResolver{definitions: s.Definitions}
+	resolved, errs := r.resolveStructural(s, field.NewPath("^"), map[string]bool{})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	resolved.Definitions = nil
+	return resolved, nil
+}
+
+// ValidateStructuralWithRefs resolves $ref/$defs in s and validates the
+// expanded tree with ValidateStructural, so the structurality invariants
+// (root must be type: object, additionalProperties forbidden at root, the
+// IntOrString exceptions, ...) are checked on the schema actually used for
+// validation rather than on the pre-expansion shorthand.
+func ValidateStructuralWithRefs(s *Structural, fldPath *field.Path) field.ErrorList {
+	resolved, errs := ResolveRefs(s)
+	if len(errs) > 0 {
+		return errs
+	}
+	return ValidateStructural(resolved, fldPath)
+}
+
+// refResolver holds the $defs a single ResolveRefs call expands against.
+type refResolver struct {
+	definitions map[string]*Structural
+}
+
+func (r *refResolver) resolveStructural(s *Structural, fldPath *field.Path, seen map[string]bool) (*Structural, field.ErrorList) {
+	if s == nil {
+		return nil, nil
+	}
+
+	if s.Ref != "" {
+		def, name, err := r.enter(s.Ref, fldPath, seen)
+		if err != nil {
+			return nil, field.ErrorList{err}
+		}
+		return r.resolveStructural(def, fldPath, withSeen(seen, name))
+	}
+
+	allErrs := field.ErrorList{}
+	out := *s
+	out.Ref = ""
+
+	if s.Items != nil {
+		items, errs := r.resolveStructural(s.Items, fldPath.Child("items"), seen)
+		allErrs = append(allErrs, errs...)
+		out.Items = items
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]Structural, len(s.Properties))
+		for k, v := range s.Properties {
+			resolved, errs := r.resolveStructural(&v, fldPath.Child("properties").Key(k), seen)
+			allErrs = append(allErrs, errs...)
+			if resolved != nil {
+				out.Properties[k] = *resolved
+			}
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Structural != nil {
+		resolved, errs := r.resolveStructural(s.AdditionalProperties.Structural, fldPath.Child("additionalProperties"), seen)
+		allErrs = append(allErrs, errs...)
+		out.AdditionalProperties = &StructuralOrBool{Structural: resolved, Bool: s.AdditionalProperties.Bool}
+	}
+	if s.ValueValidation != nil {
+		vv, errs := r.resolveValueValidation(s.ValueValidation, fldPath, seen)
+		allErrs = append(allErrs, errs...)
+		out.ValueValidation = vv
+	}
+
+	if len(allErrs) > 0 {
+		return nil, allErrs
+	}
+	return &out, nil
+}
+
+func (r *refResolver) resolveValueValidation(v *ValueValidation, fldPath *field.Path, seen map[string]bool) (*ValueValidation, field.ErrorList) {
+	if v == nil {
+		return nil, nil
+	}
+
+	allErrs := field.ErrorList{}
+	out := *v
+
+	resolveList := func(in []NestedValueValidation, child string) []NestedValueValidation {
+		if in == nil {
+			return nil
+		}
+		list := make([]NestedValueValidation, 0, len(in))
+		for i := range in {
+			resolved, errs := r.resolveNestedValueValidation(&in[i], fldPath.Child(child).Index(i), seen)
+			allErrs = append(allErrs, errs...)
+			if resolved != nil {
+				list = append(list, *resolved)
+			}
+		}
+		return list
+	}
+
+	out.AllOf = resolveList(v.AllOf, "allOf")
+	out.AnyOf = resolveList(v.AnyOf, "anyOf")
+	out.OneOf = resolveList(v.OneOf, "oneOf")
+
+	var errs field.ErrorList
+	out.Not, errs = r.resolveNestedValueValidation(v.Not, fldPath.Child("not"), seen)
+	allErrs = append(allErrs, errs...)
+	out.If, errs = r.resolveNestedValueValidation(v.If, fldPath.Child("if"), seen)
+	allErrs = append(allErrs, errs...)
+	out.Then, errs = r.resolveNestedValueValidation(v.Then, fldPath.Child("then"), seen)
+	allErrs = append(allErrs, errs...)
+	out.Else, errs = r.resolveNestedValueValidation(v.Else, fldPath.Child("else"), seen)
+	allErrs = append(allErrs, errs...)
+
+	if len(allErrs) > 0 {
+		return nil, allErrs
+	}
+	return &out, nil
+}
+
+func (r *refResolver) resolveNestedValueValidation(n *NestedValueValidation, fldPath *field.Path, seen map[string]bool) (*NestedValueValidation, field.ErrorList) {
+	if n == nil {
+		return nil, nil
+	}
+
+	if n.Ref != "" {
+		def, name, err := r.enter(n.Ref, fldPath, seen)
+		if err != nil {
+			return nil, field.ErrorList{err}
+		}
+		resolved, errs := r.resolveStructural(def, fldPath, withSeen(seen, name))
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return structuralToNested(resolved), nil
+	}
+
+	allErrs := field.ErrorList{}
+	out := *n
+	out.Ref = ""
+
+	vv, errs := r.resolveValueValidation(&n.ValueValidation, fldPath, seen)
+	allErrs = append(allErrs, errs...)
+	if vv != nil {
+		out.ValueValidation = *vv
+	}
+
+	if n.Items != nil {
+		items, errs := r.resolveNestedValueValidation(n.Items, fldPath.Child("items"), seen)
+		allErrs = append(allErrs, errs...)
+		out.Items = items
+	}
+	if n.Properties != nil {
+		out.Properties = make(map[string]NestedValueValidation, len(n.Properties))
+		for k, v := range n.Properties {
+			resolved, errs := r.resolveNestedValueValidation(&v, fldPath.Child("properties").Key(k), seen)
+			allErrs = append(allErrs, errs...)
+			if resolved != nil {
+				out.Properties[k] = *resolved
+			}
+		}
+	}
+
+	if len(allErrs) > 0 {
+		return nil, allErrs
+	}
+	return &out, nil
+}
+
+// enter resolves ref to its definition, rejecting cycles and refs that point
+// outside #/$defs/. It returns the definition name so the caller can extend
+// seen for the recursive call into that definition's body.
+func (r *refResolver) enter(ref string, fldPath *field.Path, seen map[string]bool) (*Structural, string, *field.Error) {
+	if !strings.HasPrefix(ref, definitionsPrefix) {
+		return nil, "", field.Forbidden(fldPath.Child("$ref"), fmt.Sprintf("must point to %s, got %q", definitionsPrefix, ref))
+	}
+	name := strings.TrimPrefix(ref, definitionsPrefix)
+	if seen[name] {
+		return nil, "", field.Forbidden(fldPath.Child("$ref"), fmt.Sprintf("circular reference to %q", ref))
+	}
+	def, ok := r.definitions[name]
+	if !ok {
+		return nil, "", field.Forbidden(fldPath.Child("$ref"), fmt.Sprintf("refers to undefined $defs entry %q", name))
+	}
+	return def, name, nil
+}
+
+func withSeen(seen map[string]bool, name string) map[string]bool {
+	out := make(map[string]bool, len(seen)+1)
+	for k, v := range seen {
+		out[k] = v
+	}
+	out[name] = true
+	return out
+}
+
+// structuralToNested converts a resolved Structural definition into a
+// NestedValueValidation so it can be inlined under allOf/anyOf/oneOf/not/
+// if/then/else. Its Generic and Extensions move into ForbiddenGenerics and
+// ForbiddenExtensions, so a $defs entry that itself sets e.g. a default or
+// description is still flagged as non-structural by
+// validateNestedValueValidation once inlined, exactly as if it had been
+// written out in place.
+func structuralToNested(s *Structural) *NestedValueValidation {
+	if s == nil {
+		return nil
+	}
+
+	n := &NestedValueValidation{
+		ForbiddenGenerics:   s.Generic,
+		ForbiddenExtensions: s.Extensions,
+	}
+	if s.ValueValidation != nil {
+		n.ValueValidation = *s.ValueValidation
+	}
+	if s.Items != nil {
+		n.Items = structuralToNested(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		n.Properties = make(map[string]NestedValueValidation, len(s.Properties))
+		for k, v := range s.Properties {
+			n.Properties[k] = *structuralToNested(&v)
+		}
+	}
+	return n
+}
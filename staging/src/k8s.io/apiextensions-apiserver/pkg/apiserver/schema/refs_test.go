@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestResolveRefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   *Structural
+		wantErrs int
+	}{
+		{
+			name: "direct cycle is rejected",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"self": {Ref: "#/$defs/Self"},
+				},
+				Definitions: map[string]*Structural{
+					"Self": {Ref: "#/$defs/Self"},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "indirect A -> B -> A cycle is rejected",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"a": {Ref: "#/$defs/A"},
+				},
+				Definitions: map[string]*Structural{
+					"A": {Ref: "#/$defs/B"},
+					"B": {Ref: "#/$defs/A"},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "ref outside #/$defs/ is rejected",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"bad": {Ref: "#/definitions/Old"},
+				},
+				Definitions: map[string]*Structural{
+					"Old": {Generic: Generic{Type: "string"}},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "undefined ref is rejected",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"missing": {Ref: "#/$defs/DoesNotExist"},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "well-formed ref resolves without error",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"name": {Ref: "#/$defs/Name"},
+				},
+				Definitions: map[string]*Structural{
+					"Name": {Generic: Generic{Type: "string"}},
+				},
+			},
+			wantErrs: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, errs := ResolveRefs(tt.schema)
+			if len(errs) != tt.wantErrs {
+				t.Fatalf("ResolveRefs() errs = %v, want %d error(s)", errs, tt.wantErrs)
+			}
+			if tt.wantErrs > 0 {
+				return
+			}
+			if resolved.Definitions != nil {
+				t.Errorf("resolved schema still carries Definitions: %v", resolved.Definitions)
+			}
+		})
+	}
+
+	t.Run("expanding the same definition at two call sites produces independently mutable copies", func(t *testing.T) {
+		schema := &Structural{
+			Generic: Generic{Type: "object"},
+			Properties: map[string]Structural{
+				"a": {Ref: "#/$defs/Named"},
+				"b": {Ref: "#/$defs/Named"},
+			},
+			Definitions: map[string]*Structural{
+				"Named": {
+					Generic: Generic{Type: "object"},
+					Properties: map[string]Structural{
+						"name": {Generic: Generic{Type: "string"}},
+					},
+				},
+			},
+		}
+		resolved, errs := ResolveRefs(schema)
+		if len(errs) != 0 {
+			t.Fatalf("ResolveRefs() errs = %v, want none", errs)
+		}
+		a := resolved.Properties["a"]
+		nameProp := a.Properties["name"]
+		nameProp.Type = "mutated"
+		a.Properties["name"] = nameProp
+
+		b := resolved.Properties["b"]
+		if b.Properties["name"].Type == "mutated" {
+			t.Errorf("mutating resolved.Properties[%q] leaked into resolved.Properties[%q]: shared state from the shared $defs entry", "a", "b")
+		}
+	})
+}
+
+func TestValidateStructuralWithRefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   *Structural
+		wantErrs int
+	}{
+		{
+			name: "ref to a non-object at the root violates the structural root invariant",
+			schema: &Structural{
+				Ref: "#/$defs/Name",
+				Definitions: map[string]*Structural{
+					"Name": {Generic: Generic{Type: "string"}},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "ref to a well-formed object root is structural",
+			schema: &Structural{
+				Ref: "#/$defs/Root",
+				Definitions: map[string]*Structural{
+					"Root": {Generic: Generic{Type: "object"}},
+				},
+			},
+			wantErrs: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateStructuralWithRefs(tt.schema, field.NewPath("^"))
+			if len(errs) != tt.wantErrs {
+				t.Errorf("ValidateStructuralWithRefs() = %v, want %d error(s)", errs, tt.wantErrs)
+			}
+		})
+	}
+}
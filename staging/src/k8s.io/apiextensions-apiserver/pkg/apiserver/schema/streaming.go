@@ -0,0 +1,750 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// StreamingValidator validates a serialized custom resource against a
+// structural schema one JSON token at a time, instead of unmarshaling the
+// whole document into a map[string]interface{} first. It is meant for large
+// custom resources (multi-MB ConfigMap-like CRs) where a full in-memory
+// decode would be wasteful.
+//
+// The token walk covers Type/Enum, numeric/string bounds and
+// object/array bounds (required, minProperties/maxProperties,
+// minItems/maxItems/uniqueItems) without ever holding more than the
+// currently open objects/arrays in memory. A subschema that uses allOf,
+// anyOf, oneOf, not, if, then or else cannot be evaluated incrementally -
+// answering "does this value match the if schema" needs the whole subtree at
+// once - so Validate buffers just that subtree into memory and falls back to
+// a plain, non-streaming validation of it; the rest of the document is still
+// streamed. The same buffering happens, one element at a time, for an
+// object/array element of a uniqueItems array, since deciding whether it
+// duplicates an earlier element needs its fully decoded value too. Format is
+// not checked, neither in the token walk nor in a buffered subtree.
+//
+// A StreamingValidator is not safe for concurrent use, but a *Structural can
+// be shared across StreamingValidators validating different documents
+// concurrently.
+type StreamingValidator struct {
+	schema *Structural
+
+	// schemaCache memoizes the sub-schema resolved for a given schema path so
+	// that repeated structures, most notably the elements of a large array,
+	// don't re-walk Properties/Items/AdditionalProperties once per token.
+	schemaCache map[string]*schemaCacheEntry
+}
+
+// schemaCacheEntry is what schemaCache stores for a schema path: the
+// resolved child schema (nil if unconstrained), and whether the path denotes
+// an object key that additionalProperties forbids outright.
+type schemaCacheEntry struct {
+	schema    *Structural
+	forbidden bool
+}
+
+// NewStreamingValidator returns a StreamingValidator that checks documents
+// against s.
+func NewStreamingValidator(s *Structural) *StreamingValidator {
+	return &StreamingValidator{
+		schema:      s,
+		schemaCache: map[string]*schemaCacheEntry{},
+	}
+}
+
+// frame tracks the validation state of one open object or array while r is
+// being decoded.
+type frame struct {
+	schema *Structural
+	// fldPath is this frame's instance path, e.g. `items[3].name` - used for
+	// error reporting.
+	fldPath *field.Path
+	// schemaPath is this frame's path through the *schema* shape, e.g.
+	// `items[].name`, with array indices collapsed to `[]` so that every
+	// element of an array shares one schemaPath. It is what schemaCache is
+	// keyed on, so that hot paths like list items don't re-walk
+	// Properties/Items/AdditionalProperties once per element.
+	schemaPath string
+
+	isArray bool
+
+	// object state
+	pendingKey string
+	expectKey  bool
+	seen       map[string]bool
+
+	// array state
+	itemCount int
+	uniques   map[string]bool
+	duplicate bool
+}
+
+// Validate decodes r token by token and validates each scalar, object and
+// array against the sub-schema of v's structural schema that corresponds to
+// its position in the document, reporting each violation as a field.Error
+// at the instance path of the offending value. A subtree
+// whose schema uses allOf/anyOf/oneOf/not/if/then/else is buffered into
+// memory to evaluate those keywords; everything else is validated without
+// holding more than the currently open objects/arrays in memory.
+func (v *StreamingValidator) Validate(r io.Reader) field.ErrorList {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	allErrs := field.ErrorList{}
+	var stack []*frame
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.InternalError(v.currentPath(stack), err))
+			break
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				child, fp, schemaPath, errs := v.childSlot(top)
+				allErrs = append(allErrs, errs...)
+
+				if needsFullEvaluation(child) || arrayRequiresDeepUniqueCheck(top) {
+					raw, err := readRawAfterToken(dec, t)
+					if err != nil {
+						allErrs = append(allErrs, field.InternalError(fp, err))
+					} else {
+						allErrs = append(allErrs, validateRaw(raw, child, fp)...)
+						if arrayRequiresDeepUniqueCheck(top) {
+							checkUniqueContainer(top, raw)
+						}
+					}
+					if top != nil {
+						advanceParent(top)
+					}
+					continue
+				}
+
+				allErrs = append(allErrs, containerTypeErrors(t, child, fp)...)
+				stack = append(stack, &frame{
+					schema:     child,
+					fldPath:    fp,
+					schemaPath: schemaPath,
+					isArray:    t == '[',
+					expectKey:  t == '{',
+					seen:       map[string]bool{},
+					uniques:    map[string]bool{},
+				})
+			case '}':
+				allErrs = append(allErrs, closeObject(top)...)
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 {
+					advanceParent(stack[len(stack)-1])
+				}
+			case ']':
+				allErrs = append(allErrs, closeArray(top)...)
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 {
+					advanceParent(stack[len(stack)-1])
+				}
+			}
+		default:
+			if top != nil && !top.isArray && top.expectKey {
+				key, _ := t.(string)
+				top.pendingKey = key
+				top.seen[key] = true
+				top.expectKey = false
+				continue
+			}
+
+			child, fp, _, errs := v.childSlot(top)
+			allErrs = append(allErrs, errs...)
+			if needsFullEvaluation(child) {
+				allErrs = append(allErrs, validateRaw(t, child, fp)...)
+			} else {
+				allErrs = append(allErrs, validateScalarType(t, child, fp)...)
+			}
+			if top != nil {
+				allErrs = append(allErrs, checkUniqueScalar(top, t)...)
+				advanceParent(top)
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// currentPath returns the instance path of whatever is currently open, for
+// reporting decode errors that abort the token stream.
+func (v *StreamingValidator) currentPath(stack []*frame) *field.Path {
+	if len(stack) == 0 {
+		return field.NewPath("")
+	}
+	return stack[len(stack)-1].fldPath
+}
+
+// advanceParent records that one more child value was consumed by f, moving
+// an object frame back to "expecting a key" and bumping an array frame's
+// item count.
+func advanceParent(f *frame) {
+	if f.isArray {
+		f.itemCount++
+	} else {
+		f.expectKey = true
+	}
+}
+
+// childSlot resolves the sub-schema, instance path and schema path for the
+// value about to be opened or read: a property of top (for an object frame),
+// the next item of top (for an array frame), or the root value itself when
+// top is nil, i.e. nothing has been opened yet. It consults and populates
+// v.schemaCache so that hot paths like list items don't re-walk
+// Properties/Items/AdditionalProperties every element.
+//
+// The returned field.ErrorList carries a single Forbidden error when the key
+// is rejected by additionalProperties: false, or by additionalProperties
+// left unset, which CRD structural schemas - unlike plain JSON Schema -
+// treat as forbidding additional properties rather than allowing them.
+func (v *StreamingValidator) childSlot(top *frame) (*Structural, *field.Path, string, field.ErrorList) {
+	if top == nil {
+		return v.schema, field.NewPath(""), "", nil
+	}
+
+	if top.isArray {
+		fp := top.fldPath.Index(top.itemCount)
+		schemaPath := top.schemaPath + "[]"
+		if cached, ok := v.schemaCache[schemaPath]; ok {
+			return cached.schema, fp, schemaPath, nil
+		}
+		entry := &schemaCacheEntry{}
+		if top.schema != nil {
+			entry.schema = top.schema.Items
+		}
+		v.schemaCache[schemaPath] = entry
+		return entry.schema, fp, schemaPath, nil
+	}
+
+	key := top.pendingKey
+	fp := top.fldPath.Child(key)
+	schemaPath := top.schemaPath + "." + key
+
+	entry, ok := v.schemaCache[schemaPath]
+	if !ok {
+		entry = &schemaCacheEntry{}
+		if top.schema != nil {
+			if prop, ok := top.schema.Properties[key]; ok {
+				entry.schema = &prop
+			} else if ap := top.schema.AdditionalProperties; ap != nil && (ap.Bool || ap.Structural != nil) {
+				entry.schema = ap.Structural
+			} else {
+				entry.forbidden = true
+			}
+		}
+		v.schemaCache[schemaPath] = entry
+	}
+
+	var errs field.ErrorList
+	if entry.forbidden {
+		errs = field.ErrorList{field.Forbidden(fp, "additional properties are not allowed")}
+	}
+	return entry.schema, fp, schemaPath, errs
+}
+
+// containerTypeErrors reports a field.Invalid error if s declares a Type
+// that disagrees with the delimiter t just read from the document - '{'
+// must open a declared "object" and '[' must open a declared "array" - the
+// same check validateRaw already makes for a buffered map/array value, but
+// made here at the moment a frame is pushed so the streaming path (which
+// never holds a whole object/array in memory) catches it too.
+func containerTypeErrors(t json.Delim, s *Structural, fldPath *field.Path) field.ErrorList {
+	if s == nil || s.Type == "" {
+		return nil
+	}
+	found := "object"
+	if t == '[' {
+		found = "array"
+	}
+	if s.Type != found {
+		return field.ErrorList{field.Invalid(fldPath, found, fmt.Sprintf("must be of type %s", s.Type))}
+	}
+	return nil
+}
+
+// closeObject finalizes validation of an object frame once its closing '}'
+// has been read: required fields and size bounds from ValueValidation.
+func closeObject(f *frame) field.ErrorList {
+	if f.schema == nil || f.schema.ValueValidation == nil {
+		return nil
+	}
+	vv := f.schema.ValueValidation
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, requiredPropertyErrors(vv.Required, func(k string) bool { return f.seen[k] }, f.fldPath)...)
+	allErrs = append(allErrs, objectSizeErrors(vv, len(f.seen), f.fldPath)...)
+	return allErrs
+}
+
+// closeArray finalizes validation of an array frame once its closing ']' has
+// been read: minItems/maxItems/uniqueItems from ValueValidation.
+func closeArray(f *frame) field.ErrorList {
+	if f.schema == nil || f.schema.ValueValidation == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, arraySizeErrors(f.schema.ValueValidation, f.itemCount, f.fldPath)...)
+	if f.schema.ValueValidation.UniqueItems && f.duplicate {
+		allErrs = append(allErrs, field.Duplicate(f.fldPath, "items"))
+	}
+	return allErrs
+}
+
+// requiredPropertyErrors reports a field.Required error for every name in
+// required that has(name) says is missing.
+func requiredPropertyErrors(required []string, has func(string) bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, req := range required {
+		if !has(req) {
+			allErrs = append(allErrs, field.Required(fldPath.Child(req), ""))
+		}
+	}
+	return allErrs
+}
+
+// objectSizeErrors checks propCount against vv's minProperties/maxProperties.
+func objectSizeErrors(vv *ValueValidation, propCount int, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if min := vv.MinProperties; min != nil && int64(propCount) < *min {
+		allErrs = append(allErrs, field.Invalid(fldPath, propCount, fmt.Sprintf("must have at least %d properties", *min)))
+	}
+	if max := vv.MaxProperties; max != nil && int64(propCount) > *max {
+		allErrs = append(allErrs, field.Invalid(fldPath, propCount, fmt.Sprintf("must have at most %d properties", *max)))
+	}
+	return allErrs
+}
+
+// arraySizeErrors checks itemCount against vv's minItems/maxItems.
+func arraySizeErrors(vv *ValueValidation, itemCount int, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if min := vv.MinItems; min != nil && int64(itemCount) < *min {
+		allErrs = append(allErrs, field.Invalid(fldPath, itemCount, fmt.Sprintf("must have at least %d items", *min)))
+	}
+	if max := vv.MaxItems; max != nil && int64(itemCount) > *max {
+		allErrs = append(allErrs, field.Invalid(fldPath, itemCount, fmt.Sprintf("must have at most %d items", *max)))
+	}
+	return allErrs
+}
+
+// checkUniqueScalar records a scalar array element for the uniqueItems check
+// done when the enclosing array is closed. Object/array elements go through
+// checkUniqueContainer instead, once their value has been fully decoded.
+func checkUniqueScalar(top *frame, tok interface{}) field.ErrorList {
+	if !top.isArray || top.schema == nil || top.schema.ValueValidation == nil || !top.schema.ValueValidation.UniqueItems {
+		return nil
+	}
+	recordUniqueKey(top, tok)
+	return nil
+}
+
+// arrayRequiresDeepUniqueCheck reports whether top is an array frame whose
+// schema sets UniqueItems, meaning an object/array element about to be
+// pushed must instead be buffered (via readRawAfterToken/validateRaw) so
+// checkUniqueContainer can compare it for deep equality against the other
+// elements already seen.
+func arrayRequiresDeepUniqueCheck(top *frame) bool {
+	return top != nil && top.isArray && top.schema != nil && top.schema.ValueValidation != nil && top.schema.ValueValidation.UniqueItems
+}
+
+// checkUniqueContainer records a fully-decoded object/array element of top
+// for the uniqueItems check done when the enclosing array is closed,
+// mirroring checkUniqueScalar for scalar elements.
+func checkUniqueContainer(top *frame, raw interface{}) {
+	recordUniqueKey(top, raw)
+}
+
+// recordUniqueKey marks top.duplicate if val's key - its %T/%v
+// representation, which fmt renders with object keys sorted, giving a
+// deterministic encoding for maps and slices alike - has already been seen
+// among top's elements.
+func recordUniqueKey(top *frame, val interface{}) {
+	key := fmt.Sprintf("%T:%v", val, val)
+	if top.uniques[key] {
+		top.duplicate = true
+	}
+	top.uniques[key] = true
+}
+
+// rawUniqueItemsErrors reports a duplicate error if vv.UniqueItems is set and
+// val contains two equal elements, scalar or not - two objects/arrays are
+// equal here iff their %v representations match, which for maps is
+// order-independent (fmt sorts map keys) and for slices is order-sensitive,
+// matching JSON array equality.
+func rawUniqueItemsErrors(vv *ValueValidation, val []interface{}, fldPath *field.Path) field.ErrorList {
+	if !vv.UniqueItems {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, item := range val {
+		key := fmt.Sprintf("%T:%v", item, item)
+		if seen[key] {
+			return field.ErrorList{field.Duplicate(fldPath, "items")}
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// needsFullEvaluation reports whether s's value validation uses a JSON
+// Schema logic keyword (allOf/anyOf/oneOf/not/if/then/else). Evaluating any
+// of these requires the whole subtree at once - e.g. "does this object match
+// the if schema" - so Validate buffers such a subtree instead of streaming
+// it token by token.
+func needsFullEvaluation(s *Structural) bool {
+	if s == nil || s.ValueValidation == nil {
+		return false
+	}
+	vv := s.ValueValidation
+	return len(vv.AllOf) > 0 || len(vv.AnyOf) > 0 || len(vv.OneOf) > 0 || vv.Not != nil || vv.If != nil || vv.Then != nil || vv.Else != nil
+}
+
+// readRawAfterToken reconstructs the JSON value starting at tok - which must
+// already have been read from dec via dec.Token() - as one of nil, bool,
+// json.Number, string, map[string]interface{} or []interface{}, consuming
+// the rest of it (including its closing delimiter, if any) from dec. It
+// mirrors what dec.Decode would produce, but can be called mid-stream, after
+// tok has already been consumed.
+func readRawAfterToken(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := map[string]interface{}{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			valTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := readRawAfterToken(dec, valTok)
+			if err != nil {
+				return nil, err
+			}
+			obj[keyTok.(string)] = val
+		}
+		if _, err := dec.Token(); err != nil { // consume '}'
+			return nil, err
+		}
+		return obj, nil
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			valTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := readRawAfterToken(dec, valTok)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		if _, err := dec.Token(); err != nil { // consume ']'
+			return nil, err
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("unexpected JSON delimiter %q", delim)
+	}
+}
+
+// validateRaw validates an already-decoded JSON value against s, including
+// the allOf/anyOf/oneOf/not/if/then/else keywords that Validate's
+// token-by-token walk cannot evaluate without the whole subtree in memory.
+func validateRaw(raw interface{}, s *Structural, fldPath *field.Path) field.ErrorList {
+	if s == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		if s.Type != "" && s.Type != "object" {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be of type %s", s.Type)))
+		}
+		for key, childVal := range val {
+			var childSchema *Structural
+			if prop, ok := s.Properties[key]; ok {
+				childSchema = &prop
+			} else if ap := s.AdditionalProperties; ap != nil && (ap.Bool || ap.Structural != nil) {
+				childSchema = ap.Structural
+			} else {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child(key), "additional properties are not allowed"))
+				continue
+			}
+			allErrs = append(allErrs, validateRaw(childVal, childSchema, fldPath.Child(key))...)
+		}
+		if s.ValueValidation != nil {
+			allErrs = append(allErrs, requiredPropertyErrors(s.ValueValidation.Required, func(k string) bool { _, ok := val[k]; return ok }, fldPath)...)
+			allErrs = append(allErrs, objectSizeErrors(s.ValueValidation, len(val), fldPath)...)
+		}
+	case []interface{}:
+		if s.Type != "" && s.Type != "array" {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be of type %s", s.Type)))
+		}
+		for i, item := range val {
+			allErrs = append(allErrs, validateRaw(item, s.Items, fldPath.Index(i))...)
+		}
+		if s.ValueValidation != nil {
+			allErrs = append(allErrs, arraySizeErrors(s.ValueValidation, len(val), fldPath)...)
+			allErrs = append(allErrs, rawUniqueItemsErrors(s.ValueValidation, val, fldPath)...)
+		}
+	default:
+		allErrs = append(allErrs, validateScalarType(raw, s, fldPath)...)
+	}
+
+	allErrs = append(allErrs, validateRawLogic(raw, s.ValueValidation, fldPath)...)
+
+	return allErrs
+}
+
+// validateRawLogic evaluates the allOf/anyOf/oneOf/not/if/then/else entries
+// of vv against raw.
+func validateRawLogic(raw interface{}, vv *ValueValidation, fldPath *field.Path) field.ErrorList {
+	if vv == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	for i := range vv.AllOf {
+		allErrs = append(allErrs, validateRawAgainstNested(raw, &vv.AllOf[i], fldPath.Child("allOf").Index(i))...)
+	}
+
+	if len(vv.AnyOf) > 0 && !matchesAny(raw, vv.AnyOf) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("anyOf"), raw, "must match at least one of the anyOf schemas"))
+	}
+
+	if len(vv.OneOf) > 0 {
+		matches := 0
+		for i := range vv.OneOf {
+			if matchesNested(raw, &vv.OneOf[i]) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("oneOf"), raw, fmt.Sprintf("must match exactly one of the oneOf schemas, matched %d", matches)))
+		}
+	}
+
+	if vv.Not != nil && matchesNested(raw, vv.Not) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("not"), raw, "must not match the not schema"))
+	}
+
+	if vv.If != nil {
+		if matchesNested(raw, vv.If) {
+			allErrs = append(allErrs, validateRawAgainstNested(raw, vv.Then, fldPath.Child("then"))...)
+		} else {
+			allErrs = append(allErrs, validateRawAgainstNested(raw, vv.Else, fldPath.Child("else"))...)
+		}
+	}
+
+	return allErrs
+}
+
+// matchesAny reports whether raw matches at least one of candidates.
+func matchesAny(raw interface{}, candidates []NestedValueValidation) bool {
+	for i := range candidates {
+		if matchesNested(raw, &candidates[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNested reports whether raw satisfies n, i.e. validating it against
+// n produces no errors. A nil n matches everything, mirroring how an absent
+// then/else branch imposes no additional constraint.
+func matchesNested(raw interface{}, n *NestedValueValidation) bool {
+	if n == nil {
+		return true
+	}
+	return len(validateRawAgainstNested(raw, n, field.NewPath(""))) == 0
+}
+
+// validateRawAgainstNested validates raw against the value validation and
+// items/properties carried by a NestedValueValidation node (the constraints
+// under an allOf/anyOf/oneOf/not/if/then/else branch). ForbiddenGenerics and
+// ForbiddenExtensions are invariants of the schema itself, checked by
+// validateNestedValueValidation, and are not data constraints, so they are
+// not considered here.
+func validateRawAgainstNested(raw interface{}, n *NestedValueValidation, fldPath *field.Path) field.ErrorList {
+	if n == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateRawLogic(raw, &n.ValueValidation, fldPath)...)
+
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		allErrs = append(allErrs, requiredPropertyErrors(n.ValueValidation.Required, func(k string) bool { _, ok := val[k]; return ok }, fldPath)...)
+		allErrs = append(allErrs, objectSizeErrors(&n.ValueValidation, len(val), fldPath)...)
+		for key, prop := range n.Properties {
+			if childVal, ok := val[key]; ok {
+				allErrs = append(allErrs, validateRawAgainstNested(childVal, &prop, fldPath.Child(key))...)
+			}
+		}
+	case []interface{}:
+		allErrs = append(allErrs, arraySizeErrors(&n.ValueValidation, len(val), fldPath)...)
+		allErrs = append(allErrs, rawUniqueItemsErrors(&n.ValueValidation, val, fldPath)...)
+		if n.Items != nil {
+			for i, item := range val {
+				allErrs = append(allErrs, validateRawAgainstNested(item, n.Items, fldPath.Index(i))...)
+			}
+		}
+	default:
+		allErrs = append(allErrs, validateScalarBounds(raw, &n.ValueValidation, fldPath)...)
+	}
+
+	return allErrs
+}
+
+// validateScalarType dispatches the type/enum/min/max checks from s's
+// ValueValidation against the single scalar value val. Format is not checked.
+func validateScalarType(val interface{}, s *Structural, fldPath *field.Path) field.ErrorList {
+	if s == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+
+	switch v := val.(type) {
+	case nil:
+		if !s.Nullable {
+			allErrs = append(allErrs, field.Invalid(fldPath, v, "must not be null"))
+		}
+		return allErrs
+	case bool:
+		if s.Type != "" && s.Type != "boolean" {
+			allErrs = append(allErrs, field.Invalid(fldPath, v, fmt.Sprintf("must be of type %s", s.Type)))
+		}
+	case json.Number:
+		isInteger := !strings.ContainsAny(string(v), ".eE")
+		if s.Type == "integer" && !isInteger && !s.XIntOrString {
+			allErrs = append(allErrs, field.Invalid(fldPath, v, "must be an integer"))
+		} else if s.Type != "" && s.Type != "integer" && s.Type != "number" && !s.XIntOrString {
+			allErrs = append(allErrs, field.Invalid(fldPath, v, fmt.Sprintf("must be of type %s", s.Type)))
+		}
+	case string:
+		if s.Type != "" && s.Type != "string" && !s.XIntOrString {
+			allErrs = append(allErrs, field.Invalid(fldPath, v, fmt.Sprintf("must be of type %s", s.Type)))
+		}
+	}
+
+	allErrs = append(allErrs, validateScalarBounds(val, s.ValueValidation, fldPath)...)
+
+	return allErrs
+}
+
+// validateScalarBounds applies the type-agnostic min/max/enum checks of vv to
+// val, dispatching on val's dynamic type. vv.Format is not checked.
+func validateScalarBounds(val interface{}, vv *ValueValidation, fldPath *field.Path) field.ErrorList {
+	if vv == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+	switch v := val.(type) {
+	case json.Number:
+		allErrs = append(allErrs, validateNumberBounds(v, vv, fldPath)...)
+	case string:
+		allErrs = append(allErrs, validateStringBounds(v, vv, fldPath)...)
+	}
+	allErrs = append(allErrs, validateEnum(val, vv, fldPath)...)
+	return allErrs
+}
+
+func validateNumberBounds(val json.Number, vv *ValueValidation, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	f, err := val.Float64()
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, val, "must be a number")}
+	}
+	if vv.Maximum != nil {
+		if vv.ExclusiveMaximum && f >= *vv.Maximum {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be less than %v", *vv.Maximum)))
+		} else if !vv.ExclusiveMaximum && f > *vv.Maximum {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be less than or equal to %v", *vv.Maximum)))
+		}
+	}
+	if vv.Minimum != nil {
+		if vv.ExclusiveMinimum && f <= *vv.Minimum {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be greater than %v", *vv.Minimum)))
+		} else if !vv.ExclusiveMinimum && f < *vv.Minimum {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be greater than or equal to %v", *vv.Minimum)))
+		}
+	}
+	if vv.MultipleOf != nil && *vv.MultipleOf != 0 {
+		if quotient := f / *vv.MultipleOf; quotient != float64(int64(quotient)) {
+			allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be a multiple of %v", *vv.MultipleOf)))
+		}
+	}
+	return allErrs
+}
+
+func validateStringBounds(val string, vv *ValueValidation, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	length := int64(len([]rune(val)))
+	if vv.MinLength != nil && length < *vv.MinLength {
+		allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be at least %d characters long", *vv.MinLength)))
+	}
+	if vv.MaxLength != nil && length > *vv.MaxLength {
+		allErrs = append(allErrs, field.Invalid(fldPath, val, fmt.Sprintf("must be at most %d characters long", *vv.MaxLength)))
+	}
+	return allErrs
+}
+
+func validateEnum(val interface{}, vv *ValueValidation, fldPath *field.Path) field.ErrorList {
+	if len(vv.Enum) == 0 {
+		return nil
+	}
+	want := fmt.Sprintf("%v", val)
+	for _, e := range vv.Enum {
+		if fmt.Sprintf("%v", e.Object) == want {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported[string](fldPath, val, nil)}
+}
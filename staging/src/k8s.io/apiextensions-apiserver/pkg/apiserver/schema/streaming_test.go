@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamingValidatorValidate(t *testing.T) {
+	minLen := int64(2)
+
+	tests := []struct {
+		name     string
+		schema   *Structural
+		document string
+		wantErrs int
+	}{
+		{
+			name: "required field present",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"name": {Generic: Generic{Type: "string"}},
+				},
+				ValueValidation: &ValueValidation{Required: []string{"name"}},
+			},
+			document: `{"name":"a"}`,
+			wantErrs: 0,
+		},
+		{
+			name: "required field missing",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"name": {Generic: Generic{Type: "string"}},
+				},
+				ValueValidation: &ValueValidation{Required: []string{"name"}},
+			},
+			document: `{}`,
+			wantErrs: 1,
+		},
+		{
+			name: "string shorter than minLength",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"name": {
+						Generic:         Generic{Type: "string"},
+						ValueValidation: &ValueValidation{MinLength: &minLen},
+					},
+				},
+			},
+			document: `{"name":"a"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "array longer than maxItems",
+			schema: &Structural{
+				Generic:         Generic{Type: "array"},
+				Items:           &Structural{Generic: Generic{Type: "string"}},
+				ValueValidation: &ValueValidation{MaxItems: int64Ptr(1)},
+			},
+			document: `["a","b"]`,
+			wantErrs: 1,
+		},
+		{
+			name: "uniqueItems violated by a duplicate scalar",
+			schema: &Structural{
+				Generic:         Generic{Type: "array"},
+				Items:           &Structural{Generic: Generic{Type: "string"}},
+				ValueValidation: &ValueValidation{UniqueItems: true},
+			},
+			document: `["a","a"]`,
+			wantErrs: 1,
+		},
+		{
+			name: "uniqueItems satisfied",
+			schema: &Structural{
+				Generic:         Generic{Type: "array"},
+				Items:           &Structural{Generic: Generic{Type: "string"}},
+				ValueValidation: &ValueValidation{UniqueItems: true},
+			},
+			document: `["a","b"]`,
+			wantErrs: 0,
+		},
+		{
+			name: "type mismatch on a scalar property",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"count": {Generic: Generic{Type: "integer"}},
+				},
+			},
+			document: `{"count":"not-a-number"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "nested array of objects validates each element independently",
+			schema: &Structural{
+				Generic: Generic{Type: "array"},
+				Items: &Structural{
+					Generic: Generic{Type: "object"},
+					Properties: map[string]Structural{
+						"name": {Generic: Generic{Type: "string"}},
+					},
+					ValueValidation: &ValueValidation{Required: []string{"name"}},
+				},
+			},
+			document: `[{"name":"a"},{},{"name":"c"}]`,
+			wantErrs: 1,
+		},
+		{
+			name: "object substituted for a declared array property",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"tags": {
+						Generic: Generic{Type: "array"},
+						Items:   &Structural{Generic: Generic{Type: "string"}},
+					},
+				},
+			},
+			document: `{"tags":{}}`,
+			wantErrs: 1,
+		},
+		{
+			name: "uniqueItems violated by a duplicate object",
+			schema: &Structural{
+				Generic: Generic{Type: "array"},
+				Items: &Structural{
+					Generic: Generic{Type: "object"},
+					Properties: map[string]Structural{
+						"name": {Generic: Generic{Type: "string"}},
+					},
+				},
+				ValueValidation: &ValueValidation{UniqueItems: true},
+			},
+			document: `[{"name":"a"},{"name":"a"}]`,
+			wantErrs: 1,
+		},
+		{
+			name: "additionalProperties false rejects unknown keys",
+			schema: &Structural{
+				Generic: Generic{
+					Type:                 "object",
+					AdditionalProperties: &StructuralOrBool{Bool: false},
+				},
+				Properties: map[string]Structural{
+					"name": {Generic: Generic{Type: "string"}},
+				},
+			},
+			document: `{"name":"a","unknown":"x"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "additionalProperties schema validates unknown keys against it",
+			schema: &Structural{
+				Generic: Generic{
+					Type: "object",
+					AdditionalProperties: &StructuralOrBool{
+						Structural: &Structural{Generic: Generic{Type: "integer"}},
+					},
+				},
+			},
+			document: `{"unknown":"not-a-number"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "uniqueItems violated inside a buffered subtree with a vacuous allOf",
+			schema: &Structural{
+				Generic: Generic{Type: "array"},
+				Items:   &Structural{Generic: Generic{Type: "string"}},
+				ValueValidation: &ValueValidation{
+					UniqueItems: true,
+					AllOf:       []NestedValueValidation{{}},
+				},
+			},
+			document: `["a","a"]`,
+			wantErrs: 1,
+		},
+		{
+			name: "anyOf evaluated against a scalar property",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"color": {
+						Generic: Generic{Type: "string"},
+						ValueValidation: &ValueValidation{
+							AnyOf: []NestedValueValidation{
+								{ValueValidation: ValueValidation{Enum: []JSON{{Object: "red"}}}},
+								{ValueValidation: ValueValidation{Enum: []JSON{{Object: "blue"}}}},
+							},
+						},
+					},
+				},
+			},
+			document: `{"color":"green"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "if/then evaluated against a buffered subtree",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"type":           {Generic: Generic{Type: "string"}},
+					"loadBalancerIP": {Generic: Generic{Type: "string"}},
+				},
+				ValueValidation: &ValueValidation{
+					If: &NestedValueValidation{
+						Properties: map[string]NestedValueValidation{
+							"type": {ValueValidation: ValueValidation{Enum: []JSON{{Object: "LoadBalancer"}}}},
+						},
+					},
+					Then: &NestedValueValidation{
+						ValueValidation: ValueValidation{Required: []string{"loadBalancerIP"}},
+					},
+				},
+			},
+			document: `{"type":"LoadBalancer"}`,
+			wantErrs: 1,
+		},
+		{
+			name: "then branch enforces minItems on a nested property",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"type": {Generic: Generic{Type: "string"}},
+					"items": {
+						Generic: Generic{Type: "array"},
+						Items:   &Structural{Generic: Generic{Type: "string"}},
+					},
+				},
+				ValueValidation: &ValueValidation{
+					If: &NestedValueValidation{
+						Properties: map[string]NestedValueValidation{
+							"type": {ValueValidation: ValueValidation{Enum: []JSON{{Object: "strict"}}}},
+						},
+					},
+					Then: &NestedValueValidation{
+						Properties: map[string]NestedValueValidation{
+							"items": {ValueValidation: ValueValidation{MinItems: int64Ptr(2)}},
+						},
+					},
+				},
+			},
+			document: `{"type":"strict","items":["a"]}`,
+			wantErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewStreamingValidator(tt.schema)
+			errs := v.Validate(strings.NewReader(tt.document))
+			if len(errs) != tt.wantErrs {
+				t.Errorf("Validate() = %v, want %d error(s)", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 { return &i }
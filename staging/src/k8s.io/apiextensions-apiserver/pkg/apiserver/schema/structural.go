@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+// Structural represents a structural schema: a schema which is "useful" in
+// the sense that it restricts the GVK to actual shapes of objects, i.e. it
+// specifies types for all properties, and does not use `x-kubernetes-*`
+// extensions or `anyOf`, `allOf`, `oneOf`, `not` to escape that restriction.
+type Structural struct {
+	Items      *Structural
+	Properties map[string]Structural
+
+	Generic
+	Extensions
+
+	ValueValidation *ValueValidation
+
+	// Ref is a `#/$defs/Name` reference to a reusable subschema kept in the
+	// root Structural's Definitions. If set, it replaces the rest of this
+	// Structural once resolved by ResolveRefs.
+	Ref string
+
+	// Definitions holds reusable subschemas that Ref can point into. It is
+	// only meaningful on the root Structural passed to ResolveRefs; nested
+	// Structurals do not carry their own Definitions.
+	Definitions map[string]*Structural
+}
+
+// Generic holds generic schema fields that are not specific to the v1
+// validation (and most of them are actually deprecated in validation schemas
+// used for CRD schemas).
+type Generic struct {
+	Type        string
+	Format      string
+	Title       string
+	Description string
+	Nullable    bool
+
+	Default JSON
+
+	AdditionalProperties *StructuralOrBool
+}
+
+// Extensions holds the Kubernetes extensions to JSON Schema (`x-kubernetes-*`).
+type Extensions struct {
+	// XPreserveUnknownFields means keep unknown fields, i.e. this field and
+	// its inner fields accept unknown fields with arbitrary types.
+	XPreserveUnknownFields bool
+
+	// XEmbeddedResource defines that this value is an embedded Kubernetes
+	// runtime.Object, with TypeMeta and ObjectMeta. The type must be object.
+	XEmbeddedResource bool
+
+	// XIntOrString defines that this value is either an integer or a string.
+	// If true, scalar fields in the schema (Properties, AdditionalProperties,
+	// Items) are forbidden.
+	XIntOrString bool
+}
+
+// StructuralOrBool is either a structural schema or a boolean.
+type StructuralOrBool struct {
+	Structural *Structural
+	Bool       bool
+}
+
+// JSON wraps a arbitrary JSON value, allowing it to be nil.
+type JSON struct {
+	Object interface{}
+}
+
+// ValueValidation contains all the value validation fields users can specify,
+// and is embedded into NestedValueValidation and Structural.
+type ValueValidation struct {
+	Format           string
+	Maximum          *float64
+	ExclusiveMaximum bool
+	Minimum          *float64
+	ExclusiveMinimum bool
+	MaxLength        *int64
+	MinLength        *int64
+	Pattern          string
+	MaxItems         *int64
+	MinItems         *int64
+	UniqueItems      bool
+	MultipleOf       *float64
+	Enum             []JSON
+	MaxProperties    *int64
+	MinProperties    *int64
+	Required         []string
+	AllOf            []NestedValueValidation
+	OneOf            []NestedValueValidation
+	AnyOf            []NestedValueValidation
+	Not              *NestedValueValidation
+
+	// If, Then and Else implement the draft-07 conditional keywords: when a
+	// value validates against If, it must also validate against Then (if
+	// set); otherwise it must validate against Else (if set).
+	If   *NestedValueValidation
+	Then *NestedValueValidation
+	Else *NestedValueValidation
+}
+
+// NestedValueValidation holds value validation fields under a logic junctor
+// (`allOf`, `anyOf`, `oneOf`, `not`) or a conditional keyword (`if`,
+// `then`, `else`). Those fields must not carry any
+// generics or extensions to keep the schema structural, which is enforced
+// via ForbiddenGenerics and ForbiddenExtensions.
+type NestedValueValidation struct {
+	ValueValidation
+
+	Items      *NestedValueValidation
+	Properties map[string]NestedValueValidation
+
+	ForbiddenGenerics   Generic
+	ForbiddenExtensions Extensions
+
+	// Ref is a `#/$defs/Name` reference, resolved the same way as
+	// Structural.Ref. A referenced Structural is converted into a
+	// NestedValueValidation by moving its Generic/Extensions into
+	// ForbiddenGenerics/ForbiddenExtensions, so a definition that itself
+	// uses generics or extensions is still caught as non-structural once
+	// inlined here.
+	Ref string
+}
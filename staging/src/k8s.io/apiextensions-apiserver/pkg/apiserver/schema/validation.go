@@ -191,6 +191,10 @@ func validateValueValidation(v *ValueValidation, skipAnyOf, skipFirstAllOfAnyOf
 
 	allErrs = append(allErrs, validateNestedValueValidation(v.Not, false, false, fldPath.Child("not"))...)
 
+	allErrs = append(allErrs, validateNestedValueValidation(v.If, false, false, fldPath.Child("if"))...)
+	allErrs = append(allErrs, validateNestedValueValidation(v.Then, false, false, fldPath.Child("then"))...)
+	allErrs = append(allErrs, validateNestedValueValidation(v.Else, false, false, fldPath.Child("else"))...)
+
 	return allErrs
 }
 
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateStructuralIfThenElse(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   *Structural
+		wantErrs int
+	}{
+		{
+			name: "forbidden generics under if/then/else are rejected like under allOf",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"loadBalancerIP": {Generic: Generic{Type: "string"}},
+				},
+				ValueValidation: &ValueValidation{
+					If:   &NestedValueValidation{ForbiddenGenerics: Generic{Type: "string"}},
+					Then: &NestedValueValidation{ForbiddenGenerics: Generic{Type: "string"}},
+					Else: &NestedValueValidation{ForbiddenGenerics: Generic{Type: "string"}},
+				},
+			},
+			wantErrs: 3,
+		},
+		{
+			name: "well-formed if referencing a property declared outside value validation",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				Properties: map[string]Structural{
+					"type": {Generic: Generic{Type: "string"}},
+				},
+				ValueValidation: &ValueValidation{
+					If: &NestedValueValidation{
+						Properties: map[string]NestedValueValidation{
+							"type": {},
+						},
+					},
+				},
+			},
+			wantErrs: 0,
+		},
+		{
+			name: "then referencing a property not specified outside value validation fails completeness",
+			schema: &Structural{
+				Generic: Generic{Type: "object"},
+				ValueValidation: &ValueValidation{
+					Then: &NestedValueValidation{
+						Properties: map[string]NestedValueValidation{
+							"loadBalancerIP": {},
+						},
+					},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "IntOrString anyOf exception is recognized at the top level but not inside if",
+			schema: &Structural{
+				Generic:    Generic{Type: "object"},
+				Extensions: Extensions{XIntOrString: true},
+				ValueValidation: &ValueValidation{
+					AnyOf: intOrStringAnyOf,
+					If: &NestedValueValidation{
+						ValueValidation: ValueValidation{AnyOf: intOrStringAnyOf},
+					},
+				},
+			},
+			// the top-level anyOf is the recognized `type: integer`/`type:
+			// string` shape and produces no errors; the identical shape
+			// nested under `if` isn't granted the same exception, same as
+			// it already isn't under oneOf/not, so its two `type` fields
+			// are still forbidden.
+			wantErrs: 2,
+		},
+		{
+			name: "IntOrString allOf-wrapped-anyOf exception is recognized at the top level but not inside then",
+			schema: &Structural{
+				Generic:    Generic{Type: "object"},
+				Extensions: Extensions{XIntOrString: true},
+				ValueValidation: &ValueValidation{
+					AllOf: []NestedValueValidation{
+						{ValueValidation: ValueValidation{AnyOf: intOrStringAnyOf}},
+					},
+					Then: &NestedValueValidation{
+						ValueValidation: ValueValidation{
+							AllOf: []NestedValueValidation{
+								{ValueValidation: ValueValidation{AnyOf: intOrStringAnyOf}},
+							},
+						},
+					},
+				},
+			},
+			// the top-level allOf[0].anyOf is the recognized shape and
+			// produces no errors; the same shape nested under `then` is
+			// validated as a plain allOf/anyOf, so its two `type` fields
+			// are still forbidden.
+			wantErrs: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateStructural(tt.schema, field.NewPath("^"))
+			if len(errs) != tt.wantErrs {
+				t.Errorf("ValidateStructural() = %v, want %d error(s)", errs, tt.wantErrs)
+			}
+		})
+	}
+}